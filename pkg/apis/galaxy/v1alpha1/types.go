@@ -0,0 +1,84 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// FloatingIPPool is a namespaced pool of floating ips, replacing what used to
+// be a single section of the galaxy-ipam.json config file. It lets operators
+// add/remove subnets and tune release policy defaults via GitOps instead of
+// restarting galaxy-ipam.
+type FloatingIPPool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   FloatingIPPoolSpec   `json:"spec"`
+	Status FloatingIPPoolStatus `json:"status,omitempty"`
+}
+
+// FloatingIPPoolSpec is the desired state of a FloatingIPPool.
+type FloatingIPPoolSpec struct {
+	// Subnets lists the CIDR ranges this pool allocates from.
+	Subnets []FloatingIPSubnet `json:"subnets"`
+	// ReleasePolicy is the default release policy for pods allocated from
+	// this pool when the pod itself doesn't override it via annotation.
+	// +optional
+	ReleasePolicy string `json:"releasePolicy,omitempty"`
+	// NodeSubnets optionally restricts which routable host subnets this
+	// pool's ips may be bound to.
+	// +optional
+	NodeSubnets []string `json:"nodeSubnets,omitempty"`
+}
+
+// FloatingIPSubnet describes a single allocatable range within a pool.
+type FloatingIPSubnet struct {
+	Subnet         string `json:"subnet"`
+	Gateway        string `json:"gateway"`
+	Vlan           int32  `json:"vlan,omitempty"`
+	RoutableSubnet string `json:"routableSubnet,omitempty"`
+}
+
+// FloatingIPPoolStatus is the observed state of a FloatingIPPool, updated by
+// the controller in pkg/ipam/crd.
+type FloatingIPPoolStatus struct {
+	// Allocated is the number of ips currently bound to pods.
+	Allocated int32 `json:"allocated"`
+	// Total is the number of ips the pool's subnets provide.
+	Total int32 `json:"total"`
+	// Conditions surfaces reconciliation state, e.g. a subnet that failed to
+	// parse or overlaps another pool.
+	// +optional
+	Conditions []FloatingIPPoolCondition `json:"conditions,omitempty"`
+}
+
+// FloatingIPPoolConditionType is the type of a FloatingIPPoolCondition.
+type FloatingIPPoolConditionType string
+
+const (
+	// FloatingIPPoolReady indicates the pool's subnets were all parsed and
+	// merged into the in-memory ipam store successfully.
+	FloatingIPPoolReady FloatingIPPoolConditionType = "Ready"
+)
+
+// FloatingIPPoolCondition is a single observation of a FloatingIPPool's
+// state.
+type FloatingIPPoolCondition struct {
+	Type               FloatingIPPoolConditionType `json:"type"`
+	Status             metav1.ConditionStatus      `json:"status"`
+	LastTransitionTime metav1.Time                 `json:"lastTransitionTime,omitempty"`
+	Reason             string                      `json:"reason,omitempty"`
+	Message            string                      `json:"message,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// FloatingIPPoolList is a list of FloatingIPPool.
+type FloatingIPPoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []FloatingIPPool `json:"items"`
+}
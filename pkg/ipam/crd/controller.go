@@ -0,0 +1,252 @@
+// Package crd reconciles the FloatingIPPool CRD into galaxy-ipam's in-memory
+// pool set, replacing the hardcoded --config JSON file as the source of
+// truth for subnets once the controller has bootstrapped.
+package crd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	galaxyv1alpha1 "git.code.oa.com/gaiastack/galaxy/pkg/apis/galaxy/v1alpha1"
+	"github.com/golang/glog"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// PoolConfigurator is the interface the controller reconciles FloatingIPPool
+// objects through: add/remove a named pool's subnets without dropping live
+// allocations, and report current occupancy for status.
+//
+// NOTE: there is no concrete implementation of this interface in this tree
+// yet. floatingip.IPAM (git.code.oa.com/gaiastack/galaxy/pkg/ipam/floatingip)
+// isn't vendored here, so the only methods on it this package can verify
+// (via pkg/ipam/schedulerplugin's usage) are allocation-record operations
+// (AllocateSpecificIP, ByIP, ByPrefix, First, Name, UpdateKey) - none of
+// which expose the subnet add/remove primitives ConfigurePool/RemovePool
+// need. A real adapter has to be written against floatingip.IPAM's actual
+// source, not guessed at here.
+type PoolConfigurator interface {
+	ConfigurePool(name string, subnets []galaxyv1alpha1.FloatingIPSubnet) error
+	RemovePool(name string) error
+	PoolOccupancy(name string) (allocated, total int32, err error)
+}
+
+// PoolSubnetObserver is notified as FloatingIPPool specs are reconciled,
+// e.g. to keep schedulerplugin's NetworkPolicy-aware pool hints (see
+// schedulerplugin/netpol_integration.go) fed with real subnet and node
+// subnet data instead of an empty pool list. A Controller built with a nil
+// observer just skips the notification.
+type PoolSubnetObserver interface {
+	OnPoolSubnetsChanged(name string, spec galaxyv1alpha1.FloatingIPPoolSpec)
+	OnPoolRemoved(name string)
+}
+
+// FloatingIPPoolsGetter is the thin client the controller needs to talk to
+// the API server. A real clientset generated by code-generator from
+// pkg/apis/galaxy/v1alpha1 satisfies this trivially; it is declared here so
+// the controller doesn't depend on generated code that isn't checked in yet.
+type FloatingIPPoolsGetter interface {
+	List(opts metav1.ListOptions) (*galaxyv1alpha1.FloatingIPPoolList, error)
+	Watch(opts metav1.ListOptions) (watch.Interface, error)
+	UpdateStatus(pool *galaxyv1alpha1.FloatingIPPool) (*galaxyv1alpha1.FloatingIPPool, error)
+	Create(pool *galaxyv1alpha1.FloatingIPPool) (*galaxyv1alpha1.FloatingIPPool, error)
+}
+
+// Controller watches FloatingIPPool objects and reconciles them into the
+// in-memory ipam pool set.
+type Controller struct {
+	client       FloatingIPPoolsGetter
+	configurator PoolConfigurator
+	// namespace is where bootstrapFromJSON creates pools, since
+	// FloatingIPPool is namespaced. It does not otherwise scope the
+	// controller: client is assumed to already be namespace-bound (or
+	// cluster-wide) the way a generated clientset's
+	// FloatingIPPools(namespace) accessor would be.
+	namespace string
+	observer  PoolSubnetObserver
+	informer  cache.SharedIndexInformer
+	queue     workqueue.RateLimitingInterface
+}
+
+// NewController builds a Controller. jsonConfigPath is the legacy
+// /etc/galaxy/galaxy-ipam.json location kept as a bootstrap fallback: on
+// first start, if no FloatingIPPool objects exist yet, its pools are written
+// into the CRD so existing deployments keep working without a restart-time
+// migration step. namespace is where those bootstrapped pools are created.
+// observer may be nil if nothing needs to react to subnet changes.
+func NewController(namespace string, client FloatingIPPoolsGetter, configurator PoolConfigurator,
+	observer PoolSubnetObserver) *Controller {
+	c := &Controller{
+		client:       client,
+		configurator: configurator,
+		namespace:    namespace,
+		observer:     observer,
+		queue:        workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "floatingippool"),
+	}
+	c.informer = cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+				return client.List(opts)
+			},
+			WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+				return client.Watch(opts)
+			},
+		},
+		&galaxyv1alpha1.FloatingIPPool{},
+		10*time.Minute,
+		cache.Indexers{},
+	)
+	c.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueue(obj) },
+		UpdateFunc: func(old, cur interface{}) { c.enqueue(cur) },
+		DeleteFunc: func(obj interface{}) { c.enqueue(obj) },
+	})
+	return c
+}
+
+func (c *Controller) enqueue(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		glog.Errorf("couldn't get key for %+v: %v", obj, err)
+		return
+	}
+	c.queue.Add(key)
+}
+
+// Run bootstraps from the legacy JSON config if needed, then starts the
+// informer and a single worker reconciling FloatingIPPool changes until
+// stopCh is closed.
+func (c *Controller) Run(jsonConfigPath string, stopCh <-chan struct{}) error {
+	if err := c.bootstrapFromJSON(jsonConfigPath); err != nil {
+		glog.Warningf("failed to bootstrap FloatingIPPool from %s: %v", jsonConfigPath, err)
+	}
+	go c.informer.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, c.informer.HasSynced) {
+		return fmt.Errorf("timed out waiting for FloatingIPPool cache to sync")
+	}
+	go wait.Until(c.worker, time.Second, stopCh)
+	<-stopCh
+	c.queue.ShutDown()
+	return nil
+}
+
+func (c *Controller) worker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *Controller) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+	if err := c.sync(key.(string)); err != nil {
+		glog.Warningf("failed to sync FloatingIPPool %s, will retry: %v", key, err)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+	c.queue.Forget(key)
+	return true
+}
+
+// sync reconciles a single FloatingIPPool's subnets into the in-memory ipam
+// store and writes back its status. A missing pool is treated as a delete,
+// since the shared informer's local store is the only place we can look it
+// up once the object is gone.
+func (c *Controller) sync(key string) error {
+	obj, exists, err := c.informer.GetStore().GetByKey(key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		_, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			return err
+		}
+		if err := c.configurator.RemovePool(name); err != nil {
+			return err
+		}
+		if c.observer != nil {
+			c.observer.OnPoolRemoved(name)
+		}
+		return nil
+	}
+	pool := obj.(*galaxyv1alpha1.FloatingIPPool)
+	if err := c.configurator.ConfigurePool(pool.Name, pool.Spec.Subnets); err != nil {
+		return fmt.Errorf("failed to configure pool %s: %v", pool.Name, err)
+	}
+	if c.observer != nil {
+		c.observer.OnPoolSubnetsChanged(pool.Name, pool.Spec)
+	}
+	return c.updateStatus(pool)
+}
+
+func (c *Controller) updateStatus(pool *galaxyv1alpha1.FloatingIPPool) error {
+	allocated, total, err := c.configurator.PoolOccupancy(pool.Name)
+	if err != nil {
+		return err
+	}
+	updated := pool.DeepCopy()
+	updated.Status.Allocated = allocated
+	updated.Status.Total = total
+	updated.Status.Conditions = []galaxyv1alpha1.FloatingIPPoolCondition{{
+		Type:               galaxyv1alpha1.FloatingIPPoolReady,
+		Status:             metav1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+	}}
+	_, err = c.client.UpdateStatus(updated)
+	return err
+}
+
+// legacyPoolConfig mirrors the shape of the existing galaxy-ipam.json pool
+// entries, see options.JsonConfigPath.
+type legacyPoolConfig struct {
+	Subnets []galaxyv1alpha1.FloatingIPSubnet `json:"subnets"`
+}
+
+// bootstrapFromJSON writes any pools found in the legacy JSON config file
+// into the CRD, skipping pools that already exist, so upgrading to the CRD
+// does not require a manual migration step or a restart-time drop of live
+// allocations.
+func (c *Controller) bootstrapFromJSON(jsonConfigPath string) error {
+	if jsonConfigPath == "" {
+		return nil
+	}
+	raw, err := ioutil.ReadFile(jsonConfigPath)
+	if err != nil {
+		return err
+	}
+	var legacy map[string]legacyPoolConfig
+	if err := json.Unmarshal(raw, &legacy); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", jsonConfigPath, err)
+	}
+	existing, err := c.client.List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list existing FloatingIPPools: %v", err)
+	}
+	have := map[string]bool{}
+	for i := range existing.Items {
+		have[existing.Items[i].Name] = true
+	}
+	for name, pool := range legacy {
+		if have[name] {
+			continue
+		}
+		_, err := c.client.Create(&galaxyv1alpha1.FloatingIPPool{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: c.namespace},
+			Spec:       galaxyv1alpha1.FloatingIPPoolSpec{Subnets: pool.Subnets},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create bootstrap FloatingIPPool %s: %v", name, err)
+		}
+		glog.Infof("bootstrapped FloatingIPPool %s from %s", name, jsonConfigPath)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package schedulerplugin
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// RegisterDebugRoutes wires the plugin's debugging endpoints onto mux. It is
+// called by the admin API server alongside its other debug/pprof routes.
+func (p *FloatingIPPlugin) RegisterDebugRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/ipam/podcache", p.handlePodCacheDebug)
+}
+
+// handlePodCacheDebug dumps the current ip -> pod(s) mapping so operators can
+// spot duplicate floating ip assignments that slipped through resync.
+func (p *FloatingIPPlugin) handlePodCacheDebug(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(p.podCache.Snapshot()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,43 @@
+package schedulerplugin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// FilterRequest is the scheduler-extender Filter request body: a pod plus
+// the node set the default scheduler has already narrowed things down to.
+type FilterRequest struct {
+	Pod   corev1.Pod    `json:"pod"`
+	Nodes []corev1.Node `json:"nodes"`
+}
+
+// FilterResponse is the scheduler-extender Filter response body: the subset
+// of the request's nodes that can route a floating ip for the pod, given
+// NetworkPolicy hints and pool NodeSubnets, see Filter.
+type FilterResponse struct {
+	Nodes []corev1.Node `json:"nodes"`
+}
+
+// RegisterFilterRoute registers the scheduler-extender Filter endpoint, so
+// an external kube-scheduler extender config can point at this process to
+// keep node selection consistent with floating ip pool/NetworkPolicy
+// constraints.
+func (p *FloatingIPPlugin) RegisterFilterRoute(mux *http.ServeMux) {
+	mux.HandleFunc("/filter", p.handleFilter)
+}
+
+func (p *FloatingIPPlugin) handleFilter(w http.ResponseWriter, r *http.Request) {
+	var req FilterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp := FilterResponse{Nodes: p.Filter(&req.Pod, req.Nodes)}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
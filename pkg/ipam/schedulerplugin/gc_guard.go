@@ -0,0 +1,102 @@
+package schedulerplugin
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	appv1 "k8s.io/api/apps/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultGCGracePeriod is how long a statefulset must have been observed at
+// its current generation before we trust a missing ordinal enough to release
+// its ip, see --gc-grace-period.
+const defaultGCGracePeriod = 30 * time.Second
+
+// ssGeneration records when a statefulset was first observed at its current
+// metadata.generation.
+type ssGeneration struct {
+	generation int64
+	since      time.Time
+}
+
+// ssGenerationTracker lets the release guard require that a statefulset's
+// spec has been stable for at least the grace period, so a scale-down
+// immediately followed by a scale-up doesn't race a release against the
+// recreation of the same ordinal.
+type ssGenerationTracker struct {
+	mu    sync.Mutex
+	byKey map[string]ssGeneration
+}
+
+func newSSGenerationTracker() *ssGenerationTracker {
+	return &ssGenerationTracker{byKey: make(map[string]ssGeneration)}
+}
+
+// observe records ss's current generation the first time it is seen, and
+// returns how long it has been observed at that generation.
+func (t *ssGenerationTracker) observe(ss *appv1.StatefulSet) time.Duration {
+	key := statefulsetName(ss)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	g, ok := t.byKey[key]
+	if !ok || g.generation != ss.Generation {
+		g = ssGeneration{generation: ss.Generation, since: time.Now()}
+		t.byKey[key] = g
+	}
+	return time.Since(g.since)
+}
+
+// canReleaseStatefulSetPodIP guards resyncPod/reconcileMissingPodKey's
+// statefulset-scale-down release path: a plain "pod missing, index >=
+// replicas" check races badly during rapid scale-down-then-scale-up, so we
+// additionally require the statefulset's generation to have been stable for
+// gcGracePeriod, re-confirm the replica count against the API directly
+// (not the lister, which can be stale), and refuse to release if any pod
+// with the same ordinal exists in any phase.
+func (p *FloatingIPPlugin) canReleaseStatefulSetPodIP(ss *appv1.StatefulSet, podFullName string, index int) (bool, error) {
+	if age := p.ssGenerations.observe(ss); age < p.gcGracePeriod {
+		gcReleaseSuppressed.Inc()
+		glog.V(3).Infof("suppressing release of %s: statefulset %s only stable for %v (< %v)",
+			podFullName, statefulsetName(ss), age, p.gcGracePeriod)
+		return false, nil
+	}
+	fresh, err := p.client.AppsV1().StatefulSets(ss.Namespace).Get(ss.Name, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to refetch statefulset %s from api: %v", statefulsetName(ss), err)
+	}
+	if fresh.Spec.Replicas == nil || int32(index)+1 <= *fresh.Spec.Replicas {
+		gcReleaseSuppressed.Inc()
+		return false, nil
+	}
+	exists, err := p.statefulSetOrdinalExists(ss, index)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		gcReleaseSuppressed.Inc()
+		glog.V(3).Infof("suppressing release of %s: a pod for ordinal %d already exists", podFullName, index)
+		return false, nil
+	}
+	gcReleaseExecuted.Inc()
+	return true, nil
+}
+
+// statefulSetOrdinalExists reports whether any pod owned by ss at the given
+// ordinal exists in the lister, in *any* phase including Pending and
+// ContainerCreating -- a pod that is merely still being created must not be
+// treated as "gone".
+func (p *FloatingIPPlugin) statefulSetOrdinalExists(ss *appv1.StatefulSet, index int) (bool, error) {
+	podName := fmt.Sprintf("%s-%d", ss.Name, index)
+	_, err := p.PodLister.Pods(ss.Namespace).Get(podName)
+	if err == nil {
+		return true, nil
+	}
+	if !k8serrors.IsNotFound(err) {
+		return false, err
+	}
+	return false, nil
+}
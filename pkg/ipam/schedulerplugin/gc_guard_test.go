@@ -0,0 +1,113 @@
+package schedulerplugin
+
+import (
+	"testing"
+	"time"
+
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestStatefulSet(namespace, name string, generation int64, replicas int32) *appv1.StatefulSet {
+	return &appv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Generation: generation},
+		Spec:       appv1.StatefulSetSpec{Replicas: &replicas},
+	}
+}
+
+func newTestPluginForGCGuard(client *fake.Clientset, pods ...*corev1.Pod) *FloatingIPPlugin {
+	factory := informers.NewSharedInformerFactory(client, 0)
+	podInformer := factory.Core().V1().Pods()
+	for _, pod := range pods {
+		podInformer.Informer().GetStore().Add(pod)
+	}
+	return &FloatingIPPlugin{
+		client:        client,
+		PodLister:     podInformer.Lister(),
+		ssGenerations: newSSGenerationTracker(),
+		gcGracePeriod: defaultGCGracePeriod,
+	}
+}
+
+func TestCanReleaseStatefulSetPodIPSuppressesUntilGenerationStable(t *testing.T) {
+	ss := newTestStatefulSet("ns1", "web", 1, 1)
+	client := fake.NewSimpleClientset(ss)
+	p := newTestPluginForGCGuard(client)
+	p.gcGracePeriod = time.Hour
+
+	ok, err := p.canReleaseStatefulSetPodIP(ss, "ns1_web-1", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok {
+		t.Fatalf("expected release to be suppressed while the statefulset generation is still fresh")
+	}
+}
+
+func TestCanReleaseStatefulSetPodIPSuppressesIfReplicasStillCoverIndex(t *testing.T) {
+	ss := newTestStatefulSet("ns1", "web", 1, 2)
+	client := fake.NewSimpleClientset(ss)
+	p := newTestPluginForGCGuard(client)
+	p.gcGracePeriod = 0
+
+	ok, err := p.canReleaseStatefulSetPodIP(ss, "ns1_web-1", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok {
+		t.Fatalf("expected release to be suppressed: ordinal 1 is still within replicas=2")
+	}
+}
+
+func TestCanReleaseStatefulSetPodIPSuppressesIfOrdinalPodExists(t *testing.T) {
+	ss := newTestStatefulSet("ns1", "web", 1, 1)
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "web-1"}}
+	client := fake.NewSimpleClientset(ss)
+	p := newTestPluginForGCGuard(client, pod)
+	p.gcGracePeriod = 0
+
+	ok, err := p.canReleaseStatefulSetPodIP(ss, "ns1_web-1", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok {
+		t.Fatalf("expected release to be suppressed: a pod for ordinal 1 still exists")
+	}
+}
+
+func TestCanReleaseStatefulSetPodIPAllowsReleaseWhenScaledDown(t *testing.T) {
+	ss := newTestStatefulSet("ns1", "web", 1, 1)
+	client := fake.NewSimpleClientset(ss)
+	p := newTestPluginForGCGuard(client)
+	p.gcGracePeriod = 0
+
+	ok, err := p.canReleaseStatefulSetPodIP(ss, "ns1_web-1", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ok {
+		t.Fatalf("expected release to be allowed: ordinal 1 is beyond replicas=1 and no pod exists for it")
+	}
+}
+
+func TestCanReleaseStatefulSetPodIPRefetchesFromAPI(t *testing.T) {
+	// The statefulset passed in says replicas=1 (ordinal 1 releasable), but
+	// the API server now reports replicas=2 (e.g. a scale-up the lister
+	// hasn't caught up with yet): the guard must trust the refetch, not ss.
+	staleSS := newTestStatefulSet("ns1", "web", 1, 1)
+	freshSS := newTestStatefulSet("ns1", "web", 1, 2)
+	client := fake.NewSimpleClientset(freshSS)
+	p := newTestPluginForGCGuard(client)
+	p.gcGracePeriod = 0
+
+	ok, err := p.canReleaseStatefulSetPodIP(staleSS, "ns1_web-1", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok {
+		t.Fatalf("expected release to be suppressed based on the refetched replica count")
+	}
+}
@@ -0,0 +1,27 @@
+package schedulerplugin
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// gcReleaseSuppressed counts statefulset ip releases the gc grace-period
+	// guard declined to perform, e.g. because the generation was too fresh
+	// or the ordinal's pod still exists.
+	gcReleaseSuppressed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "galaxy_ipam",
+		Subsystem: "statefulset_gc",
+		Name:      "suppressed_total",
+		Help:      "Number of statefulset pod ip releases suppressed by the gc grace-period guard",
+	})
+	// gcReleaseExecuted counts statefulset ip releases the guard allowed
+	// through to completion.
+	gcReleaseExecuted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "galaxy_ipam",
+		Subsystem: "statefulset_gc",
+		Name:      "executed_total",
+		Help:      "Number of statefulset pod ip releases executed after passing the gc grace-period guard",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(gcReleaseSuppressed, gcReleaseExecuted)
+}
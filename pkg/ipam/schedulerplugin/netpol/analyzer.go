@@ -0,0 +1,145 @@
+// Package netpol lets floating ip allocation take NetworkPolicy peers into
+// account: a pod covered by a policy whose ingress/egress peers are confined
+// to a given CIDR should prefer a pool whose subnet is actually reachable
+// from there, instead of operators having to hand-annotate pools.
+package netpol
+
+import (
+	"net"
+	"sync"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// PoolSubnet is the slice of a pool's configuration the analyzer needs to
+// decide reachability: just the CIDR it allocates from.
+type PoolSubnet struct {
+	PoolID string
+	CIDR   *net.IPNet
+}
+
+// Analyzer computes, for each NetworkPolicy, the set of pool IDs whose
+// subnets satisfy the policy's ingress.from/egress.to peer CIDR constraints,
+// and caches the result so Filter/allocate can look it up in O(1).
+type Analyzer struct {
+	mu sync.RWMutex
+	// pools is the current set of pools to evaluate policies against, kept
+	// up to date by whoever owns the pool configuration (e.g. pkg/ipam/crd).
+	pools []PoolSubnet
+	// cache maps a policy's namespace/name key to the pool ids it permits.
+	cache map[string]sets.Set[string]
+}
+
+// NewAnalyzer creates an empty Analyzer.
+func NewAnalyzer() *Analyzer {
+	return &Analyzer{cache: make(map[string]sets.Set[string])}
+}
+
+// SetPools replaces the pool subnets the analyzer evaluates policies
+// against and invalidates the cache, since a pool add/remove can change
+// every policy's allowed set.
+func (a *Analyzer) SetPools(pools []PoolSubnet) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pools = pools
+	a.cache = make(map[string]sets.Set[string])
+}
+
+// OnPolicyAddOrUpdate recomputes and caches the pool ids reachable from
+// policy's ingress.from/egress.to peers.
+func (a *Analyzer) OnPolicyAddOrUpdate(policy *networkingv1.NetworkPolicy) {
+	key := policyKey(policy.Namespace, policy.Name)
+	allowed := a.poolsReachableBy(peerCIDRs(policy))
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.cache[key] = allowed
+}
+
+// OnPolicyDelete drops a deleted policy's cached pool set.
+func (a *Analyzer) OnPolicyDelete(namespace, name string) {
+	key := policyKey(namespace, name)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.cache, key)
+}
+
+// PoolsForPolicies returns the intersection of the cached pool sets for the
+// given policy namespace/names. An empty result means no constraint could be
+// resolved for any of them, signalling the caller to fall back to
+// unconstrained allocation; ok is false if none of the policies have a
+// cached entry yet.
+func (a *Analyzer) PoolsForPolicies(namespace string, names []string) (allowed sets.Set[string], ok bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for i, name := range names {
+		pools, found := a.cache[policyKey(namespace, name)]
+		if !found {
+			continue
+		}
+		if !ok {
+			allowed = pools.Clone()
+			ok = true
+			continue
+		}
+		if i > 0 {
+			allowed = allowed.Intersection(pools)
+		}
+	}
+	return allowed, ok
+}
+
+// poolsReachableBy returns the ids of every pool subnet that is contained in
+// (or contains) at least one of the given peer CIDRs. No peer CIDRs at all
+// (e.g. a policy with no ipBlock peers) yields no constraint.
+func (a *Analyzer) poolsReachableBy(peers []*net.IPNet) sets.Set[string] {
+	if len(peers) == 0 {
+		return sets.New[string]()
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	result := sets.New[string]()
+	for _, pool := range a.pools {
+		for _, peer := range peers {
+			if cidrsOverlap(pool.CIDR, peer) {
+				result.Insert(pool.PoolID)
+				break
+			}
+		}
+	}
+	return result
+}
+
+func cidrsOverlap(a, b *net.IPNet) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// peerCIDRs extracts every ipBlock CIDR referenced by a policy's ingress
+// from/egress to peers.
+func peerCIDRs(policy *networkingv1.NetworkPolicy) []*net.IPNet {
+	var cidrs []*net.IPNet
+	add := func(peers []networkingv1.NetworkPolicyPeer) {
+		for _, peer := range peers {
+			if peer.IPBlock == nil {
+				continue
+			}
+			if _, ipnet, err := net.ParseCIDR(peer.IPBlock.CIDR); err == nil {
+				cidrs = append(cidrs, ipnet)
+			}
+		}
+	}
+	for _, rule := range policy.Spec.Ingress {
+		add(rule.From)
+	}
+	for _, rule := range policy.Spec.Egress {
+		add(rule.To)
+	}
+	return cidrs
+}
+
+func policyKey(namespace, name string) string {
+	return namespace + "/" + name
+}
@@ -0,0 +1,134 @@
+package netpol
+
+import (
+	"net"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func mustParseCIDR(t *testing.T, s string) *net.IPNet {
+	t.Helper()
+	_, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		t.Fatalf("failed to parse CIDR %s: %v", s, err)
+	}
+	return ipnet
+}
+
+func TestCidrsOverlap(t *testing.T) {
+	cases := []struct {
+		name     string
+		a, b     string
+		expected bool
+	}{
+		{"identical", "10.0.0.0/24", "10.0.0.0/24", true},
+		{"a contains b", "10.0.0.0/16", "10.0.1.0/24", true},
+		{"b contains a", "10.0.1.0/24", "10.0.0.0/16", true},
+		{"disjoint", "10.0.0.0/24", "10.1.0.0/24", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			a := mustParseCIDR(t, c.a)
+			b := mustParseCIDR(t, c.b)
+			if got := cidrsOverlap(a, b); got != c.expected {
+				t.Errorf("cidrsOverlap(%s, %s) = %v, want %v", c.a, c.b, got, c.expected)
+			}
+		})
+	}
+}
+
+func TestCidrsOverlapNilIsNeverReachable(t *testing.T) {
+	if cidrsOverlap(nil, mustParseCIDR(t, "10.0.0.0/24")) {
+		t.Errorf("a nil CIDR should never be considered reachable")
+	}
+	if cidrsOverlap(mustParseCIDR(t, "10.0.0.0/24"), nil) {
+		t.Errorf("a nil CIDR should never be considered reachable")
+	}
+}
+
+func newIPBlockPolicy(namespace, name, cidr string) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: networkingv1.NetworkPolicySpec{
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				From: []networkingv1.NetworkPolicyPeer{{
+					IPBlock: &networkingv1.IPBlock{CIDR: cidr},
+				}},
+			}},
+		},
+	}
+}
+
+func TestPoolsForPoliciesSinglePolicy(t *testing.T) {
+	a := NewAnalyzer()
+	a.SetPools([]PoolSubnet{
+		{PoolID: "pool-a", CIDR: mustParseCIDR(t, "10.0.0.0/24")},
+		{PoolID: "pool-b", CIDR: mustParseCIDR(t, "10.1.0.0/24")},
+	})
+	a.OnPolicyAddOrUpdate(newIPBlockPolicy("ns1", "allow-a", "10.0.0.0/24"))
+
+	allowed, ok := a.PoolsForPolicies("ns1", []string{"allow-a"})
+	if !ok {
+		t.Fatalf("expected a cached result for allow-a")
+	}
+	if !allowed.Has("pool-a") || allowed.Has("pool-b") {
+		t.Errorf("PoolsForPolicies = %v, want only pool-a", allowed.UnsortedList())
+	}
+}
+
+func TestPoolsForPoliciesIntersectsMultiplePolicies(t *testing.T) {
+	a := NewAnalyzer()
+	a.SetPools([]PoolSubnet{
+		{PoolID: "pool-a", CIDR: mustParseCIDR(t, "10.0.0.0/24")},
+		{PoolID: "pool-b", CIDR: mustParseCIDR(t, "10.1.0.0/24")},
+	})
+	a.OnPolicyAddOrUpdate(newIPBlockPolicy("ns1", "allow-both", "10.0.0.0/8"))
+	a.OnPolicyAddOrUpdate(newIPBlockPolicy("ns1", "allow-a-only", "10.0.0.0/24"))
+
+	allowed, ok := a.PoolsForPolicies("ns1", []string{"allow-both", "allow-a-only"})
+	if !ok {
+		t.Fatalf("expected a cached result")
+	}
+	if allowed.Len() != 1 || !allowed.Has("pool-a") {
+		t.Errorf("PoolsForPolicies = %v, want exactly pool-a", allowed.UnsortedList())
+	}
+}
+
+func TestPoolsForPoliciesUnknownPolicyNotOK(t *testing.T) {
+	a := NewAnalyzer()
+	a.SetPools([]PoolSubnet{{PoolID: "pool-a", CIDR: mustParseCIDR(t, "10.0.0.0/24")}})
+
+	_, ok := a.PoolsForPolicies("ns1", []string{"never-seen"})
+	if ok {
+		t.Errorf("expected ok=false for a policy that was never cached")
+	}
+}
+
+func TestOnPolicyDeleteDropsCachedEntry(t *testing.T) {
+	a := NewAnalyzer()
+	a.SetPools([]PoolSubnet{{PoolID: "pool-a", CIDR: mustParseCIDR(t, "10.0.0.0/24")}})
+	a.OnPolicyAddOrUpdate(newIPBlockPolicy("ns1", "allow-a", "10.0.0.0/24"))
+	a.OnPolicyDelete("ns1", "allow-a")
+
+	_, ok := a.PoolsForPolicies("ns1", []string{"allow-a"})
+	if ok {
+		t.Errorf("expected the deleted policy's cache entry to be gone")
+	}
+}
+
+func TestSetPoolsInvalidatesCache(t *testing.T) {
+	a := NewAnalyzer()
+	a.SetPools([]PoolSubnet{{PoolID: "pool-a", CIDR: mustParseCIDR(t, "10.0.0.0/24")}})
+	a.OnPolicyAddOrUpdate(newIPBlockPolicy("ns1", "allow-a", "10.0.0.0/24"))
+
+	// pool-a's subnet is replaced with one that no longer overlaps the
+	// policy's peer CIDR.
+	a.SetPools([]PoolSubnet{{PoolID: "pool-a", CIDR: mustParseCIDR(t, "10.9.0.0/24")}})
+
+	_, ok := a.PoolsForPolicies("ns1", []string{"allow-a"})
+	if ok {
+		t.Errorf("expected SetPools to invalidate the cache built against the old pool subnets")
+	}
+}
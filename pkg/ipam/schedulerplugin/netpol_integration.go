@@ -0,0 +1,237 @@
+package schedulerplugin
+
+import (
+	"net"
+	"strings"
+
+	galaxyv1alpha1 "git.code.oa.com/gaiastack/galaxy/pkg/apis/galaxy/v1alpha1"
+	"git.code.oa.com/gaiastack/galaxy/pkg/ipam/schedulerplugin/netpol"
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NetworkPoliciesAnnotation lets a pod explicitly reference the
+// NetworkPolicies its floating ip pool should be constrained by, as a
+// comma-separated list of names in the pod's own namespace. Pods that don't
+// set it are still matched against every policy whose podSelector covers
+// their labels.
+const NetworkPoliciesAnnotation = "galaxy.k8s.io/network-policies"
+
+func (p *FloatingIPPlugin) onNetworkPolicyAddOrUpdate(obj interface{}) {
+	policy, ok := obj.(*networkingv1.NetworkPolicy)
+	if !ok {
+		return
+	}
+	p.netpolAnalyzer.OnPolicyAddOrUpdate(policy)
+}
+
+func (p *FloatingIPPlugin) onNetworkPolicyDelete(obj interface{}) {
+	policy, ok := obj.(*networkingv1.NetworkPolicy)
+	if !ok {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			policy, ok = tombstone.Obj.(*networkingv1.NetworkPolicy)
+			if !ok {
+				return
+			}
+		} else {
+			return
+		}
+	}
+	p.netpolAnalyzer.OnPolicyDelete(policy.Namespace, policy.Name)
+}
+
+// OnPoolSubnetsChanged implements crd.PoolSubnetObserver, keeping
+// netpolAnalyzer's view of pool subnets, and Filter's view of each pool's
+// routable node subnets, current as pkg/ipam/crd reconciles FloatingIPPool
+// objects. A plugin with hints disabled (netpolAnalyzer nil) still tracks
+// poolNodeSubnets, since Filter doesn't depend on netpolAnalyzer being set.
+func (p *FloatingIPPlugin) OnPoolSubnetsChanged(name string, spec galaxyv1alpha1.FloatingIPPoolSpec) {
+	pools := make([]netpol.PoolSubnet, 0, len(spec.Subnets))
+	for _, s := range spec.Subnets {
+		_, ipnet, err := net.ParseCIDR(s.Subnet)
+		if err != nil {
+			glog.Warningf("pool %s: skipping subnet %q for NetworkPolicy hints: %v", name, s.Subnet, err)
+			continue
+		}
+		pools = append(pools, netpol.PoolSubnet{PoolID: name, CIDR: ipnet})
+	}
+	nodeSubnets := make([]*net.IPNet, 0, len(spec.NodeSubnets))
+	for _, s := range spec.NodeSubnets {
+		_, ipnet, err := net.ParseCIDR(s)
+		if err != nil {
+			glog.Warningf("pool %s: skipping node subnet %q for Filter: %v", name, s, err)
+			continue
+		}
+		nodeSubnets = append(nodeSubnets, ipnet)
+	}
+	p.poolSubnetsMu.Lock()
+	defer p.poolSubnetsMu.Unlock()
+	p.poolSubnets[name] = pools
+	p.poolNodeSubnets[name] = nodeSubnets
+	p.flattenPoolSubnetsLocked()
+}
+
+// OnPoolRemoved implements crd.PoolSubnetObserver.
+func (p *FloatingIPPlugin) OnPoolRemoved(name string) {
+	p.poolSubnetsMu.Lock()
+	defer p.poolSubnetsMu.Unlock()
+	delete(p.poolSubnets, name)
+	delete(p.poolNodeSubnets, name)
+	p.flattenPoolSubnetsLocked()
+}
+
+// flattenPoolSubnetsLocked pushes the full, current pool set into
+// netpolAnalyzer. Caller must hold poolSubnetsMu.
+func (p *FloatingIPPlugin) flattenPoolSubnetsLocked() {
+	if p.netpolAnalyzer == nil {
+		return
+	}
+	var all []netpol.PoolSubnet
+	for _, pools := range p.poolSubnets {
+		all = append(all, pools...)
+	}
+	p.netpolAnalyzer.SetPools(all)
+}
+
+// knownPoolIDsLocked returns the ids of every pool Filter currently knows
+// about, i.e. every pool reconciled via OnPoolSubnetsChanged. Caller must
+// hold poolSubnetsMu.
+func (p *FloatingIPPlugin) knownPoolIDsLocked() sets.Set[string] {
+	ids := sets.New[string]()
+	for name := range p.poolNodeSubnets {
+		ids.Insert(name)
+	}
+	return ids
+}
+
+// Filter is the scheduler-extender Filter hook: it narrows nodes down to
+// those that can actually route an ip from a pool pod is allowed to
+// allocate from, applying constrainPoolsByNetworkPolicy's NetworkPolicy
+// hints first. A pool with no NodeSubnets recorded is assumed reachable
+// from every node, matching the pre-CRD behaviour where pools weren't
+// restricted by node at all.
+func (p *FloatingIPPlugin) Filter(pod *corev1.Pod, nodes []corev1.Node) []corev1.Node {
+	p.poolSubnetsMu.Lock()
+	candidates := p.knownPoolIDsLocked()
+	nodeSubnets := make(map[string][]*net.IPNet, len(p.poolNodeSubnets))
+	for name, subnets := range p.poolNodeSubnets {
+		nodeSubnets[name] = subnets
+	}
+	p.poolSubnetsMu.Unlock()
+
+	if candidates.Len() == 0 {
+		// No pools reconciled yet (or Filter called before the CRD
+		// controller is wired up): don't filter out every node.
+		return nodes
+	}
+	allowed := p.constrainPoolsByNetworkPolicy(pod, candidates)
+
+	out := make([]corev1.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if nodeInPools(&node, allowed, nodeSubnets) {
+			out = append(out, node)
+		}
+	}
+	return out
+}
+
+// nodeInPools reports whether node can route an ip from at least one pool
+// in pools, based on each pool's recorded NodeSubnets. A pool with no
+// NodeSubnets recorded (nil/empty) is treated as reachable from any node.
+func nodeInPools(node *corev1.Node, pools sets.Set[string], nodeSubnets map[string][]*net.IPNet) bool {
+	nodeIP := nodeInternalIP(node)
+	for pool := range pools {
+		subnets := nodeSubnets[pool]
+		if len(subnets) == 0 {
+			return true
+		}
+		if nodeIP == nil {
+			continue
+		}
+		for _, subnet := range subnets {
+			if subnet.Contains(nodeIP) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nodeInternalIP returns node's InternalIP, or nil if it has none.
+func nodeInternalIP(node *corev1.Node) net.IP {
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == corev1.NodeInternalIP {
+			return net.ParseIP(addr.Address)
+		}
+	}
+	return nil
+}
+
+// constrainPoolsByNetworkPolicy intersects candidates with the pool ids
+// reachable according to the NetworkPolicies covering pod, falling back to
+// the unconstrained candidates if hints aren't enabled or none of the
+// matching policies resolved to a useful constraint. Filter below is the
+// real caller: it starts from every known pool id and narrows the result to
+// the nodes that can route whatever pool(s) survive the intersection.
+func (p *FloatingIPPlugin) constrainPoolsByNetworkPolicy(pod *corev1.Pod, candidates sets.Set[string]) sets.Set[string] {
+	if p.netpolAnalyzer == nil {
+		return candidates
+	}
+	names := p.networkPoliciesForPod(pod)
+	if len(names) == 0 {
+		return candidates
+	}
+	allowed, ok := p.netpolAnalyzer.PoolsForPolicies(pod.Namespace, names)
+	if !ok || allowed.Len() == 0 {
+		return candidates
+	}
+	constrained := candidates.Intersection(allowed)
+	if constrained.Len() == 0 {
+		glog.V(3).Infof("no pool satisfies NetworkPolicy hints for pod %s/%s, falling back to unconstrained allocation",
+			pod.Namespace, pod.Name)
+		return candidates
+	}
+	return constrained
+}
+
+// networkPoliciesForPod returns the names of every NetworkPolicy that
+// applies to pod: those explicitly listed via NetworkPoliciesAnnotation,
+// plus any whose podSelector matches the pod's labels.
+func (p *FloatingIPPlugin) networkPoliciesForPod(pod *corev1.Pod) []string {
+	var names []string
+	if ann := pod.Annotations[NetworkPoliciesAnnotation]; ann != "" {
+		names = append(names, splitCommaList(ann)...)
+	}
+	policies, err := p.NetworkPolicyLister.NetworkPolicies(pod.Namespace).List(labels.Everything())
+	if err != nil {
+		glog.Warningf("failed to list network policies in %s: %v", pod.Namespace, err)
+		return names
+	}
+	for _, policy := range policies {
+		selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.PodSelector)
+		if err != nil {
+			continue
+		}
+		if selector.Matches(labels.Set(pod.Labels)) {
+			names = append(names, policy.Name)
+		}
+	}
+	return names
+}
+
+// splitCommaList splits a comma-separated annotation value, trimming
+// whitespace and dropping empty entries.
+func splitCommaList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
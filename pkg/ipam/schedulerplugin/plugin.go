@@ -0,0 +1,168 @@
+package schedulerplugin
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"git.code.oa.com/gaiastack/galaxy/pkg/ipam/floatingip"
+	"git.code.oa.com/gaiastack/galaxy/pkg/ipam/schedulerplugin/netpol"
+	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/util/wait"
+	appinformers "k8s.io/client-go/informers/apps/v1"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	networkinginformers "k8s.io/client-go/informers/networking/v1"
+	"k8s.io/client-go/kubernetes"
+	applisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	// defaultWorkers is the number of goroutines draining podQueue.
+	defaultWorkers = 5
+	// defaultResyncInterval is the period of the slower full-scan safety-net resync.
+	defaultResyncInterval = 5 * time.Minute
+)
+
+// FloatingIPPlugin releases and allocates floating ips for pods according to
+// their parent deployment/statefulset release policy.
+type FloatingIPPlugin struct {
+	client kubernetes.Interface
+
+	PodLister    corelisters.PodLister
+	PodHasSynced cache.InformerSynced
+
+	StatefulSetLister applisters.StatefulSetLister
+	StatefulSetSynced cache.InformerSynced
+
+	DeploymentLister applisters.DeploymentLister
+	DeploymentSynced cache.InformerSynced
+
+	ipam       floatingip.IPAM
+	secondIPAM floatingip.IPAM
+
+	// podCache is an ip-indexed view of known pods, used to catch duplicate
+	// floating ip assignments that a resync scan could otherwise miss.
+	podCache *PodCache
+	recorder record.EventRecorder
+
+	// ssGenerations and gcGracePeriod back the fast-scale statefulset gc
+	// guard, see gc_guard.go.
+	ssGenerations *ssGenerationTracker
+	gcGracePeriod time.Duration
+
+	// NetworkPolicyLister and netpolAnalyzer back NetworkPolicy-aware pool
+	// hints, see netpol_integration.go. Both are nil-safe: a plugin that
+	// never sets them simply never constrains allocation by policy.
+	NetworkPolicyLister networkinglisters.NetworkPolicyLister
+	NetworkPolicySynced cache.InformerSynced
+	netpolAnalyzer      *netpol.Analyzer
+
+	// poolSubnets mirrors the current FloatingIPPool CRD subnets, keyed by
+	// pool name, so netpolAnalyzer has real subnet data to match
+	// NetworkPolicy peer CIDRs against instead of an empty pool list.
+	// poolNodeSubnets mirrors each pool's optional NodeSubnets, so Filter
+	// can tell which nodes can actually route a given pool's ips. Both are
+	// kept current via OnPoolSubnetsChanged/OnPoolRemoved, see
+	// pkg/ipam/crd.PoolSubnetObserver.
+	poolSubnetsMu   sync.Mutex
+	poolSubnets     map[string][]netpol.PoolSubnet
+	poolNodeSubnets map[string][]*net.IPNet
+
+	resyncInterval time.Duration
+	workers        int
+	podQueue       workqueue.RateLimitingInterface
+
+	stopChan <-chan struct{}
+}
+
+// NewFloatingIPPlugin creates a FloatingIPPlugin and wires up the pod,
+// statefulset and deployment informers it depends on.
+func NewFloatingIPPlugin(client kubernetes.Interface, ipam, secondIPAM floatingip.IPAM,
+	podInformer coreinformers.PodInformer, ssInformer appinformers.StatefulSetInformer,
+	dpInformer appinformers.DeploymentInformer) *FloatingIPPlugin {
+	p := &FloatingIPPlugin{
+		client:            client,
+		ipam:              ipam,
+		secondIPAM:        secondIPAM,
+		PodLister:         podInformer.Lister(),
+		PodHasSynced:      podInformer.Informer().HasSynced,
+		StatefulSetLister: ssInformer.Lister(),
+		StatefulSetSynced: ssInformer.Informer().HasSynced,
+		DeploymentLister:  dpInformer.Lister(),
+		DeploymentSynced:  dpInformer.Informer().HasSynced,
+		podCache:          NewPodCache(),
+		ssGenerations:     newSSGenerationTracker(),
+		gcGracePeriod:     defaultGCGracePeriod,
+		poolSubnets:       make(map[string][]netpol.PoolSubnet),
+		poolNodeSubnets:   make(map[string][]*net.IPNet),
+		resyncInterval:    defaultResyncInterval,
+		workers:           defaultWorkers,
+		podQueue:          workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "floatingip-pod"),
+	}
+	p.registerEventHandlers(podInformer, ssInformer, dpInformer)
+	return p
+}
+
+// SetEventRecorder wires up the recorder used to emit ip conflict events.
+// Done as a setter rather than a constructor arg so callers that don't care
+// about conflict events (e.g. some tests) can skip it.
+func (p *FloatingIPPlugin) SetEventRecorder(recorder record.EventRecorder) {
+	p.recorder = recorder
+}
+
+// PodCache returns the plugin's ip-indexed pod cache, e.g. for the admin API
+// to list ip -> pod(s) when debugging conflicts.
+func (p *FloatingIPPlugin) PodCache() *PodCache {
+	return p.podCache
+}
+
+// SetGCGracePeriod overrides the default fast-scale statefulset gc grace
+// period, see --gc-grace-period.
+func (p *FloatingIPPlugin) SetGCGracePeriod(d time.Duration) {
+	p.gcGracePeriod = d
+}
+
+// EnableNetworkPolicyHints wires up NetworkPolicy-aware pool hints: pods
+// covered by a policy whose ingress/egress peers are confined to specific
+// CIDRs will prefer a pool reachable from there, see netpol_integration.go.
+func (p *FloatingIPPlugin) EnableNetworkPolicyHints(informer networkinginformers.NetworkPolicyInformer) {
+	p.NetworkPolicyLister = informer.Lister()
+	p.NetworkPolicySynced = informer.Informer().HasSynced
+	p.netpolAnalyzer = netpol.NewAnalyzer()
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { p.onNetworkPolicyAddOrUpdate(obj) },
+		UpdateFunc: func(old, cur interface{}) { p.onNetworkPolicyAddOrUpdate(cur) },
+		DeleteFunc: func(obj interface{}) { p.onNetworkPolicyDelete(obj) },
+	})
+}
+
+// Run starts the event-driven workers plus the periodic safety-net resync and
+// blocks until stopChan is closed.
+func (p *FloatingIPPlugin) Run(stopChan <-chan struct{}) {
+	defer p.podQueue.ShutDown()
+	p.stopChan = stopChan
+	glog.Infof("starting floatingip plugin with %d workers, resync every %v", p.workers, p.resyncInterval)
+	if !cache.WaitForCacheSync(stopChan, p.PodHasSynced, p.StatefulSetSynced, p.DeploymentSynced) {
+		glog.Errorf("timed out waiting for caches to sync")
+		return
+	}
+	for i := 0; i < p.workers; i++ {
+		go wait.Until(p.podWorker, time.Second, stopChan)
+	}
+	go wait.Until(p.resync, p.resyncInterval, stopChan)
+	go wait.Until(p.syncPodIPsIntoDB, p.resyncInterval, stopChan)
+	<-stopChan
+}
+
+// resync is the slower full-scan safety-net, kept so that keys dropped by the
+// event handlers (e.g. processed during a restart) are eventually reconciled.
+func (p *FloatingIPPlugin) resync() {
+	if err := p.resyncPod(p.ipam); err != nil {
+		glog.Warningf("resync: %v", err)
+	}
+}
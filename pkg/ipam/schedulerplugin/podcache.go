@@ -0,0 +1,193 @@
+package schedulerplugin
+
+import (
+	"sync"
+
+	"git.code.oa.com/gaiastack/galaxy/pkg/api/galaxy/constant"
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// PodCache is an ip-indexed view of the pods the plugin knows about, kept in
+// sync from the pod informer. It lets syncPodIP detect two different pods
+// claiming the same floating ip before the ipam binding is overwritten,
+// something a resync scan could previously miss until the next tick.
+type PodCache struct {
+	mu sync.RWMutex
+
+	podsByIP map[string]sets.Set[types.NamespacedName]
+	ipByPods map[types.NamespacedName]string
+}
+
+// NewPodCache creates an empty PodCache.
+func NewPodCache() *PodCache {
+	return &PodCache{
+		podsByIP: make(map[string]sets.Set[types.NamespacedName]),
+		ipByPods: make(map[types.NamespacedName]string),
+	}
+}
+
+// update records that name currently holds ip, pruning any stale reverse
+// mapping first so a pod that changes ip doesn't keep the old binding alive.
+func (c *PodCache) update(name types.NamespacedName, ip string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.ipByPods[name]; ok {
+		if old == ip {
+			return
+		}
+		c.removeLocked(name, old)
+	}
+	if ip == "" {
+		return
+	}
+	c.ipByPods[name] = ip
+	if c.podsByIP[ip] == nil {
+		c.podsByIP[ip] = sets.New[types.NamespacedName]()
+	}
+	c.podsByIP[ip].Insert(name)
+}
+
+// remove drops name from the cache entirely, e.g. on pod delete.
+func (c *PodCache) remove(name types.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ip, ok := c.ipByPods[name]; ok {
+		c.removeLocked(name, ip)
+	}
+}
+
+func (c *PodCache) removeLocked(name types.NamespacedName, ip string) {
+	delete(c.ipByPods, name)
+	if pods, ok := c.podsByIP[ip]; ok {
+		pods.Delete(name)
+		if pods.Len() == 0 {
+			delete(c.podsByIP, ip)
+		}
+	}
+}
+
+// owners returns the pods currently recorded as holding ip.
+func (c *PodCache) owners(ip string) sets.Set[types.NamespacedName] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.podsByIP[ip].Clone()
+}
+
+// Snapshot returns a copy of the ip -> pod(s) mapping for debugging IP
+// conflicts, e.g. via the admin API.
+func (c *PodCache) Snapshot() map[string][]types.NamespacedName {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make(map[string][]types.NamespacedName, len(c.podsByIP))
+	for ip, pods := range c.podsByIP {
+		out[ip] = pods.UnsortedList()
+	}
+	return out
+}
+
+// onPodAddOrUpdate keeps PodCache in sync with the pod informer. It is wired
+// up alongside the existing event handlers in registerEventHandlers.
+func (p *FloatingIPPlugin) onPodAddOrUpdate(pod *corev1.Pod) {
+	ip := podCacheIP(pod)
+	p.podCache.update(types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, ip)
+}
+
+func (p *FloatingIPPlugin) onPodDelete(pod *corev1.Pod) {
+	p.podCache.remove(types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name})
+}
+
+// podCacheIP returns the ip PodCache should index the pod under, preferring
+// the status ip and falling back to the extended cni args annotation so the
+// cache is populated even before kubelet reports PodIP. A pod that is no
+// longer running (completed, failed, or evicted -- see evicted() in
+// resync.go) is reported as having no ip at all, so podsByIP.update prunes
+// it instead of letting a stale PodIP that k8s leaves around until GC block
+// a legitimate new pod from ever claiming the same address.
+func podCacheIP(pod *corev1.Pod) string {
+	if !podRunning(pod) {
+		return ""
+	}
+	if pod.Status.PodIP != "" {
+		return pod.Status.PodIP
+	}
+	ipInfos, err := constant.ParseIPInfo(pod.Annotations[constant.ExtendedCNIArgsAnnotation])
+	if err != nil || len(ipInfos) == 0 || ipInfos[0].IP == nil {
+		return ""
+	}
+	return ipInfos[0].IP.IP.String()
+}
+
+// podRunning reports whether pod is still a live claimant of its ip, using
+// the same notion of "gone" resync.go already applies elsewhere (evicted, or
+// a terminal phase).
+func podRunning(pod *corev1.Pod) bool {
+	if evicted(pod) {
+		return false
+	}
+	switch pod.Status.Phase {
+	case corev1.PodSucceeded, corev1.PodFailed:
+		return false
+	default:
+		return true
+	}
+}
+
+// checkConflict refuses to hand ip to name if the cache already shows a
+// different, still-running pod holding it, emitting a conflict event on both
+// pods instead of silently overwriting the existing ipam binding.
+func (p *FloatingIPPlugin) checkConflict(name types.NamespacedName, ip string, pod *corev1.Pod) bool {
+	owners := p.podCache.owners(ip)
+	if owners.Len() == 0 {
+		return true
+	}
+	if owners.Len() == 1 && owners.Has(name) {
+		return true
+	}
+	for other := range owners {
+		if other == name {
+			continue
+		}
+		if !p.ownerStillRunning(other) {
+			// stale entry for a pod the cache hasn't pruned yet (e.g. its
+			// delete/complete event hasn't been processed): don't let it
+			// block allocation.
+			continue
+		}
+		glog.Errorf("ip conflict: %s and %s both claim %s", name, other, ip)
+		p.recordConflictEvent(pod, other, ip)
+		return false
+	}
+	return true
+}
+
+// ownerStillRunning re-checks the lister for a cached ip owner, since the
+// cache can lag an informer event that hasn't been processed yet.
+func (p *FloatingIPPlugin) ownerStillRunning(owner types.NamespacedName) bool {
+	pod, err := p.PodLister.Pods(owner.Namespace).Get(owner.Name)
+	if err != nil {
+		// gone from the lister entirely: definitely not running.
+		return false
+	}
+	return podRunning(pod)
+}
+
+// recordConflictEvent emits a Kubernetes Event on pod recording that it
+// conflicts with other over ip. Best effort: a failure to fetch the other
+// pod or write the event should never block reconciliation.
+func (p *FloatingIPPlugin) recordConflictEvent(pod *corev1.Pod, other types.NamespacedName, ip string) {
+	if p.recorder == nil {
+		return
+	}
+	p.recorder.Eventf(pod, corev1.EventTypeWarning, "FloatingIPConflict",
+		"ip %s is already bound to pod %s", ip, other)
+	otherPod, err := p.PodLister.Pods(other.Namespace).Get(other.Name)
+	if err != nil {
+		glog.Warningf("failed to fetch conflicting pod %s to record event: %v", other, err)
+		return
+	}
+	p.recorder.Eventf(otherPod, corev1.EventTypeWarning, "FloatingIPConflict",
+		"ip %s is also claimed by pod %s", ip, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name})
+}
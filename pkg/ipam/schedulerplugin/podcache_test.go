@@ -0,0 +1,192 @@
+package schedulerplugin
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPodCacheUpdateAndOwners(t *testing.T) {
+	c := NewPodCache()
+	pod := types.NamespacedName{Namespace: "ns1", Name: "web-0"}
+
+	c.update(pod, "10.0.0.1")
+
+	owners := c.owners("10.0.0.1")
+	if !owners.Has(pod) || owners.Len() != 1 {
+		t.Fatalf("owners(10.0.0.1) = %v, want just %v", owners.UnsortedList(), pod)
+	}
+}
+
+func TestPodCacheUpdatePrunesStaleIPOnChange(t *testing.T) {
+	c := NewPodCache()
+	pod := types.NamespacedName{Namespace: "ns1", Name: "web-0"}
+
+	c.update(pod, "10.0.0.1")
+	c.update(pod, "10.0.0.2")
+
+	if owners := c.owners("10.0.0.1"); owners.Len() != 0 {
+		t.Errorf("owners(10.0.0.1) = %v, want empty after pod moved to a new ip", owners.UnsortedList())
+	}
+	owners := c.owners("10.0.0.2")
+	if !owners.Has(pod) || owners.Len() != 1 {
+		t.Errorf("owners(10.0.0.2) = %v, want just %v", owners.UnsortedList(), pod)
+	}
+}
+
+func TestPodCacheUpdateSameIPIsNoop(t *testing.T) {
+	c := NewPodCache()
+	pod := types.NamespacedName{Namespace: "ns1", Name: "web-0"}
+
+	c.update(pod, "10.0.0.1")
+	c.update(pod, "10.0.0.1")
+
+	owners := c.owners("10.0.0.1")
+	if owners.Len() != 1 {
+		t.Errorf("owners(10.0.0.1) = %v, want exactly one entry", owners.UnsortedList())
+	}
+}
+
+func TestPodCacheUpdateEmptyIPPrunesWithoutAddingBack(t *testing.T) {
+	c := NewPodCache()
+	pod := types.NamespacedName{Namespace: "ns1", Name: "web-0"}
+
+	c.update(pod, "10.0.0.1")
+	c.update(pod, "")
+
+	if owners := c.owners("10.0.0.1"); owners.Len() != 0 {
+		t.Errorf("owners(10.0.0.1) = %v, want empty once the pod reports no ip", owners.UnsortedList())
+	}
+	if snap := c.Snapshot(); len(snap) != 0 {
+		t.Errorf("Snapshot() = %v, want empty", snap)
+	}
+}
+
+func TestPodCacheRemove(t *testing.T) {
+	c := NewPodCache()
+	pod := types.NamespacedName{Namespace: "ns1", Name: "web-0"}
+
+	c.update(pod, "10.0.0.1")
+	c.remove(pod)
+
+	if owners := c.owners("10.0.0.1"); owners.Len() != 0 {
+		t.Errorf("owners(10.0.0.1) = %v, want empty after remove", owners.UnsortedList())
+	}
+	if snap := c.Snapshot(); len(snap) != 0 {
+		t.Errorf("Snapshot() = %v, want empty after remove", snap)
+	}
+}
+
+func TestPodCacheOwnersUnknownIPIsEmptyNotNilPanic(t *testing.T) {
+	c := NewPodCache()
+	owners := c.owners("10.9.9.9")
+	if owners.Len() != 0 {
+		t.Errorf("owners(unknown ip) = %v, want empty", owners.UnsortedList())
+	}
+}
+
+func newTestPluginForPodCache(client *fake.Clientset, pods ...*corev1.Pod) *FloatingIPPlugin {
+	factory := informers.NewSharedInformerFactory(client, 0)
+	podInformer := factory.Core().V1().Pods()
+	for _, pod := range pods {
+		podInformer.Informer().GetStore().Add(pod)
+	}
+	return &FloatingIPPlugin{
+		client:    client,
+		PodLister: podInformer.Lister(),
+		podCache:  NewPodCache(),
+	}
+}
+
+func TestCheckConflictAllowsFirstClaim(t *testing.T) {
+	p := newTestPluginForPodCache(fake.NewSimpleClientset())
+	name := types.NamespacedName{Namespace: "ns1", Name: "web-0"}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "web-0"}}
+
+	if !p.checkConflict(name, "10.0.0.1", pod) {
+		t.Errorf("checkConflict = false, want true when no one else holds the ip")
+	}
+}
+
+func TestCheckConflictAllowsSelfReclaim(t *testing.T) {
+	p := newTestPluginForPodCache(fake.NewSimpleClientset())
+	name := types.NamespacedName{Namespace: "ns1", Name: "web-0"}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "web-0"}}
+	p.podCache.update(name, "10.0.0.1")
+
+	if !p.checkConflict(name, "10.0.0.1", pod) {
+		t.Errorf("checkConflict = false, want true when the only owner is the pod itself")
+	}
+}
+
+func TestCheckConflictRejectsStillRunningOwner(t *testing.T) {
+	other := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "web-1"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	client := fake.NewSimpleClientset(other)
+	p := newTestPluginForPodCache(client, other)
+	otherName := types.NamespacedName{Namespace: "ns1", Name: "web-1"}
+	p.podCache.update(otherName, "10.0.0.1")
+
+	name := types.NamespacedName{Namespace: "ns1", Name: "web-0"}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "web-0"}}
+
+	if p.checkConflict(name, "10.0.0.1", pod) {
+		t.Errorf("checkConflict = true, want false: %s is still running and holds the ip", otherName)
+	}
+}
+
+func TestCheckConflictIgnoresStaleOwnerNoLongerRunning(t *testing.T) {
+	// The cache hasn't been pruned yet (e.g. the delete event for "web-1"
+	// hasn't been processed), but the lister shows it's gone/terminal: the
+	// stale entry must not block a legitimate new claim.
+	client := fake.NewSimpleClientset()
+	p := newTestPluginForPodCache(client)
+	otherName := types.NamespacedName{Namespace: "ns1", Name: "web-1"}
+	p.podCache.update(otherName, "10.0.0.1")
+
+	name := types.NamespacedName{Namespace: "ns1", Name: "web-0"}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "web-0"}}
+
+	if !p.checkConflict(name, "10.0.0.1", pod) {
+		t.Errorf("checkConflict = false, want true: stale owner %s is gone from the lister", otherName)
+	}
+}
+
+func TestOwnerStillRunningFalseWhenGoneFromLister(t *testing.T) {
+	p := newTestPluginForPodCache(fake.NewSimpleClientset())
+	if p.ownerStillRunning(types.NamespacedName{Namespace: "ns1", Name: "ghost"}) {
+		t.Errorf("ownerStillRunning = true, want false for a pod missing from the lister")
+	}
+}
+
+func TestOwnerStillRunningFalseWhenTerminal(t *testing.T) {
+	completed := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "job-0"},
+		Status:     corev1.PodStatus{Phase: corev1.PodSucceeded},
+	}
+	client := fake.NewSimpleClientset(completed)
+	p := newTestPluginForPodCache(client, completed)
+
+	if p.ownerStillRunning(types.NamespacedName{Namespace: "ns1", Name: "job-0"}) {
+		t.Errorf("ownerStillRunning = true, want false for a completed pod")
+	}
+}
+
+func TestOwnerStillRunningTrueWhenRunning(t *testing.T) {
+	running := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "web-1"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	client := fake.NewSimpleClientset(running)
+	p := newTestPluginForPodCache(client, running)
+
+	if !p.ownerStillRunning(types.NamespacedName{Namespace: "ns1", Name: "web-1"}) {
+		t.Errorf("ownerStillRunning = false, want true for a running pod")
+	}
+}
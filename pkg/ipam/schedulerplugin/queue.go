@@ -0,0 +1,301 @@
+package schedulerplugin
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	appinformers "k8s.io/client-go/informers/apps/v1"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// objKind identifies which informer a queued key came from, since the pod,
+// statefulset and deployment informers share a single rate limited queue.
+type objKind string
+
+const (
+	kindPod         objKind = "pod"
+	kindStatefulSet objKind = "statefulset"
+	kindDeployment  objKind = "deployment"
+)
+
+// queueItem is what gets pushed onto podQueue. key is a namespace/name
+// produced by cache.MetaNamespaceKeyFunc and split back out by the workers
+// via cache.SplitMetaNamespaceKey.
+type queueItem struct {
+	kind objKind
+	key  string
+	// dbKey is the already-resolved ipam key for a pod that was just
+	// deleted, computed in the pod informer's DeleteFunc while the real
+	// *corev1.Pod is still available (see enqueuePodDelete). It is empty
+	// for every other queue item: by the time a plain namespace/name key
+	// reaches the worker the pod may already be gone from the lister, and
+	// keyForDeploymentPod's "_deployment_<ns>_<dp>_<pod>" format can't be
+	// reconstructed from namespace/name alone once that happens.
+	dbKey string
+}
+
+// registerEventHandlers enqueues the namespace/name of changed pods,
+// statefulsets and deployments so normal reconciliation is driven by events
+// rather than waiting for the periodic resync to notice them. This closes
+// the fast-scale-up/scale-down race where a full scan could release a
+// still-alive statefulset pod's ip between two events.
+func (p *FloatingIPPlugin) registerEventHandlers(podInformer coreinformers.PodInformer,
+	ssInformer appinformers.StatefulSetInformer, dpInformer appinformers.DeploymentInformer) {
+	podInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			p.enqueue(kindPod, obj)
+			if pod, ok := obj.(*corev1.Pod); ok {
+				p.onPodAddOrUpdate(pod)
+			}
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			p.enqueue(kindPod, cur)
+			if pod, ok := cur.(*corev1.Pod); ok {
+				p.onPodAddOrUpdate(pod)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			pod, ok := obj.(*corev1.Pod)
+			if !ok {
+				tombstone, ok2 := obj.(cache.DeletedFinalStateUnknown)
+				if !ok2 {
+					runtime.HandleError(fmt.Errorf("couldn't get pod from delete event %+v", obj))
+					return
+				}
+				pod, ok = tombstone.Obj.(*corev1.Pod)
+				if !ok {
+					runtime.HandleError(fmt.Errorf("tombstone contained non-pod object %+v", tombstone.Obj))
+					return
+				}
+			}
+			p.enqueuePodDelete(pod)
+			p.onPodDelete(pod)
+		},
+	})
+	ssInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { p.enqueue(kindStatefulSet, obj) },
+		UpdateFunc: func(old, cur interface{}) { p.enqueue(kindStatefulSet, cur) },
+		DeleteFunc: func(obj interface{}) { p.enqueue(kindStatefulSet, obj) },
+	})
+	dpInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { p.enqueue(kindDeployment, obj) },
+		UpdateFunc: func(old, cur interface{}) { p.enqueue(kindDeployment, cur) },
+		DeleteFunc: func(obj interface{}) { p.enqueue(kindDeployment, obj) },
+	})
+}
+
+func (p *FloatingIPPlugin) enqueue(kind objKind, obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		runtime.HandleError(fmt.Errorf("couldn't get key for %s %+v: %v", kind, obj, err))
+		return
+	}
+	p.podQueue.Add(queueItem{kind: kind, key: key})
+}
+
+// enqueuePodDelete resolves pod's ipam db key before it is evicted from the
+// informer store and carries it through the queue item, since once the pod
+// is gone p.podBelongToDeployment(pod) has nothing left to consult.
+func (p *FloatingIPPlugin) enqueuePodDelete(pod *corev1.Pod) {
+	key, err := cache.MetaNamespaceKeyFunc(pod)
+	if err != nil {
+		runtime.HandleError(fmt.Errorf("couldn't get key for pod %+v: %v", pod, err))
+		return
+	}
+	dbKey := keyInDB(pod)
+	if dp := p.podBelongToDeployment(pod); dp != "" {
+		dbKey = keyForDeploymentPod(pod, dp)
+	}
+	p.podQueue.Add(queueItem{kind: kindPod, key: key, dbKey: dbKey})
+}
+
+// podWorker pops items off podQueue until told to stop.
+func (p *FloatingIPPlugin) podWorker() {
+	for p.processNextItem() {
+	}
+}
+
+func (p *FloatingIPPlugin) processNextItem() bool {
+	obj, quit := p.podQueue.Get()
+	if quit {
+		return false
+	}
+	defer p.podQueue.Done(obj)
+	item := obj.(queueItem)
+	if err := p.syncHandler(item); err != nil {
+		runtime.HandleError(fmt.Errorf("sync %s %s failed: %v, will retry", item.kind, item.key, err))
+		p.podQueue.AddRateLimited(obj)
+		return true
+	}
+	p.podQueue.Forget(obj)
+	return true
+}
+
+// syncHandler runs the same policy decisions resyncPod performs during a
+// full scan, but scoped to the single object named by item so that normal
+// create/update/delete traffic no longer waits for the next periodic tick.
+func (p *FloatingIPPlugin) syncHandler(item queueItem) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(item.key)
+	if err != nil {
+		return fmt.Errorf("invalid resource key %s: %v", item.key, err)
+	}
+	switch item.kind {
+	case kindPod:
+		if item.dbKey != "" {
+			return p.releaseDeletedPodIPByKey(item.dbKey)
+		}
+		return p.syncPodByName(namespace, name)
+	case kindStatefulSet:
+		return p.syncStatefulSetByName(namespace, name)
+	case kindDeployment:
+		return p.syncDeploymentByName(namespace, name)
+	default:
+		return fmt.Errorf("unknown kind %s for key %s", item.kind, item.key)
+	}
+}
+
+// syncPodByName reconciles the ipam entry of a single pod: allocates its ip
+// into the db if it just got one, or releases/rebinds the stored entry if
+// it is evicted. A pod already gone from the lister is handled separately
+// via the dbKey captured by enqueuePodDelete, since its owning deployment
+// (if any) can no longer be looked up here.
+func (p *FloatingIPPlugin) syncPodByName(namespace, name string) error {
+	pod, err := p.PodLister.Pods(namespace).Get(name)
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return err
+		}
+		// nothing to recover here without the pod object; DeleteFunc
+		// already enqueued a dedicated item carrying the resolved dbKey.
+		return nil
+	}
+	if !p.hasResourceName(&pod.Spec) {
+		return nil
+	}
+	if evicted(pod) {
+		return p.releaseDeletedPodIPByKey(podDBKey(pod, p.podBelongToDeployment(pod)))
+	}
+	return p.syncPodIP(pod)
+}
+
+// podDBKey returns the ipam key a pod is stored under, matching
+// keyInDB/keyForDeploymentPod in resync.go.
+func podDBKey(pod *corev1.Pod, deployment string) string {
+	if deployment != "" {
+		return keyForDeploymentPod(pod, deployment)
+	}
+	return keyInDB(pod)
+}
+
+// releaseDeletedPodIPByKey applies the same release/rebind rules as
+// resyncPod to the ipam entry stored under key, without requiring a full
+// store scan.
+func (p *FloatingIPPlugin) releaseDeletedPodIPByKey(key string) error {
+	ssMap, err := p.getSSMap()
+	if err != nil {
+		return err
+	}
+	dpMap, err := p.getDPMap()
+	if err != nil {
+		return err
+	}
+	return p.reconcileIPAMKey(key, ssMap, dpMap)
+}
+
+// reconcileIPAMKey looks up the stored ipam entry for key (if any) and
+// forwards it to reconcileMissingPodKey, the shared decision function in
+// resync.go.
+func (p *FloatingIPPlugin) reconcileIPAMKey(key string, ssMap map[string]*appv1.StatefulSet,
+	dpMap map[string]*appv1.Deployment) error {
+	fip, err := p.ipam.First(key)
+	if err != nil {
+		return fmt.Errorf("failed get key %s: %v", key, err)
+	}
+	if fip == nil {
+		return nil
+	}
+	appName, _, namespace := resolveAppPodName(key)
+	if namespace == "" {
+		appName, _, namespace = resolveDpAppPodName(key)
+	}
+	return p.reconcileMissingPodKey(p.ipam, key, fmtKey(appName, namespace), fip.FIP.Attr, ssMap, dpMap)
+}
+
+// syncStatefulSetByName re-checks every ipam entry owned by a statefulset so
+// a scale down releases ordinals above the new replica count (and a scale
+// back up reclaims them) without waiting for the safety-net resync.
+func (p *FloatingIPPlugin) syncStatefulSetByName(namespace, name string) error {
+	ss, err := p.StatefulSetLister.StatefulSets(namespace).Get(name)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if !p.hasResourceName(&ss.Spec.Template.Spec) {
+		return nil
+	}
+	fips, err := p.ipam.ByPrefix(fmt.Sprintf("%s_%s-", namespace, name))
+	if err != nil {
+		return err
+	}
+	dpMap, err := p.getDPMap()
+	if err != nil {
+		return err
+	}
+	ssMap := map[string]*appv1.StatefulSet{statefulsetName(ss): ss}
+	for _, fip := range fips {
+		if err := p.reconcileIPAMKey(fip.Key, ssMap, dpMap); err != nil {
+			glog.Warningf("[%s] %v", p.ipam.Name(), err)
+		}
+	}
+	return nil
+}
+
+// syncDeploymentByName re-checks the deployment's ip pool occupancy so a
+// scale down releases surplus ips without waiting for the periodic resync.
+func (p *FloatingIPPlugin) syncDeploymentByName(namespace, name string) error {
+	dp, err := p.DeploymentLister.Deployments(namespace).Get(name)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if !p.hasResourceName(&dp.Spec.Template.Spec) {
+		return nil
+	}
+	dpKey := deploymentIPPoolPrefix(dp)
+	fips, err := p.ipam.ByPrefix(dpKey)
+	if err != nil {
+		return err
+	}
+	replicas := int(*dp.Spec.Replicas)
+	if replicas >= len(fips) {
+		return nil
+	}
+	pods, err := p.listWantedPods()
+	if err != nil {
+		return err
+	}
+	existing := map[string]bool{}
+	for _, pod := range pods {
+		if d := p.podBelongToDeployment(pod); d == name && pod.Namespace == namespace {
+			existing[keyForDeploymentPod(pod, d)] = true
+		}
+	}
+	for _, fip := range fips {
+		if existing[fip.Key] {
+			continue
+		}
+		if err := releaseIP(p.ipam, fip.Key, deletedAndScaledDownDpPod); err != nil {
+			glog.Warningf("[%s] %v", p.ipam.Name(), err)
+		}
+	}
+	return nil
+}
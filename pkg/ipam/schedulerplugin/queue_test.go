@@ -0,0 +1,54 @@
+package schedulerplugin
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestPodDBKeyPlainPod(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "web-0"}}
+	got := podDBKey(pod, "")
+	want := keyInDB(pod)
+	if got != want {
+		t.Errorf("podDBKey(pod, \"\") = %q, want %q", got, want)
+	}
+	if want != "ns1_web-0" {
+		t.Errorf("keyInDB(pod) = %q, want ns1_web-0", want)
+	}
+}
+
+func TestPodDBKeyDeploymentPod(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "web-7f8b9c-abcde"}}
+	got := podDBKey(pod, "web")
+	want := keyForDeploymentPod(pod, "web")
+	if got != want {
+		t.Errorf("podDBKey(pod, \"web\") = %q, want %q", got, want)
+	}
+	if want != "_deployment_ns1_web_web-7f8b9c-abcde" {
+		t.Errorf("keyForDeploymentPod(pod, \"web\") = %q, want _deployment_ns1_web_web-7f8b9c-abcde", want)
+	}
+}
+
+func TestEnqueuePodDeleteCarriesDBKey(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "web-0"}}
+	p := &FloatingIPPlugin{podQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "test")}
+
+	p.enqueuePodDelete(pod)
+
+	obj, _ := p.podQueue.Get()
+	item := obj.(queueItem)
+	if item.kind != kindPod {
+		t.Errorf("item.kind = %v, want kindPod", item.kind)
+	}
+	if item.key != "ns1/web-0" {
+		t.Errorf("item.key = %q, want ns1/web-0", item.key)
+	}
+	// podBelongToDeployment isn't wired up in this tree yet (see resync.go),
+	// so a plain pod's dbKey always falls back to keyInDB here.
+	if want := keyInDB(pod); item.dbKey != want {
+		t.Errorf("item.dbKey = %q, want %q", item.dbKey, want)
+	}
+}
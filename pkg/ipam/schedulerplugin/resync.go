@@ -15,6 +15,7 @@ import (
 	appv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 func (p *FloatingIPPlugin) storeReady() bool {
@@ -141,100 +142,98 @@ func (p *FloatingIPPlugin) resyncPod(ipam floatingip.IPAM) error {
 		if _, ok := existPods[podFullName]; ok {
 			continue
 		}
-		appFullName := obj.appFulName
-		// we can't get labels of not exist pod, so get them from it's ss or deployment
-		ss, ok := ssMap[appFullName]
-		if ok && !strings.HasPrefix(podFullName, "_deployment_") {
-			if !p.hasResourceName(&ss.Spec.Template.Spec) {
-				// 6. deleted pods whose parent app's labels doesn't contain network=floatingip
-				if err := releaseIP(ipam, podFullName, deletedAndLabelMissMatchPod); err != nil {
-					glog.Warningf("[%s] %v", ipam.Name(), err)
-				}
-				continue
-			}
-			if parseReleasePolicy(&ss.Spec.Template.ObjectMeta) != constant.ReleasePolicyImmutable {
-				// 2. deleted pods whose parent statefulset exist but is not ip immutable
-				if err := releaseIP(ipam, podFullName, deletedAndIPMutablePod); err != nil {
-					glog.Warningf("[%s] %v", ipam.Name(), err)
-				}
-				continue
-			}
-			index, err := parsePodIndex(podFullName)
+		if err := p.reconcileMissingPodKey(ipam, podFullName, obj.appFulName, obj.attr, ssMap, dpMap); err != nil {
+			glog.Warningf("[%s] %v", ipam.Name(), err)
+		}
+	}
+	return nil
+}
+
+// reconcileMissingPodKey applies the release/rebind rules that used to live
+// inline in resyncPod's scan loop to a single ipam key whose owning pod has
+// already been confirmed absent. It is shared by the full-scan safety-net
+// resync and the event-driven reconciler in queue.go so both paths make the
+// exact same decision for a given key.
+func (p *FloatingIPPlugin) reconcileMissingPodKey(ipam floatingip.IPAM, podFullName, appFullName, attr string,
+	ssMap map[string]*appv1.StatefulSet, dpMap map[string]*appv1.Deployment) error {
+	// we can't get labels of not exist pod, so get them from it's ss or deployment
+	ss, ok := ssMap[appFullName]
+	if ok && !strings.HasPrefix(podFullName, "_deployment_") {
+		if !p.hasResourceName(&ss.Spec.Template.Spec) {
+			// 6. deleted pods whose parent app's labels doesn't contain network=floatingip
+			return releaseIP(ipam, podFullName, deletedAndLabelMissMatchPod)
+		}
+		if parseReleasePolicy(&ss.Spec.Template.ObjectMeta) != constant.ReleasePolicyImmutable {
+			// 2. deleted pods whose parent statefulset exist but is not ip immutable
+			return releaseIP(ipam, podFullName, deletedAndIPMutablePod)
+		}
+		index, err := parsePodIndex(podFullName)
+		if err != nil {
+			return fmt.Errorf("invalid pod name %s of ss %s: %v", podFullName, statefulsetName(ss), err)
+		}
+		if ss.Spec.Replicas != nil && *ss.Spec.Replicas < int32(index)+1 {
+			// 4. deleted pods whose parent statefulset exist but pod index > *statefulset.spec.replica
+			// guarded so a fast scale-down-then-up can't race a release
+			// against the recreation of the same ordinal, see gc_guard.go
+			allow, err := p.canReleaseStatefulSetPodIP(ss, podFullName, index)
 			if err != nil {
-				glog.Errorf("invalid pod name %s of ss %s: %v", podFullName, statefulsetName(ss), err)
-				continue
+				return fmt.Errorf("gc guard for %s: %v", podFullName, err)
 			}
-			if ss.Spec.Replicas != nil && *ss.Spec.Replicas < int32(index)+1 {
-				if err := releaseIP(ipam, podFullName, deletedAndIPMutablePod); err != nil {
-					glog.Warningf("[%s] %v", ipam.Name(), err)
-				}
-				continue
+			if !allow {
+				return nil
 			}
-			continue
+			return releaseIP(ipam, podFullName, deletedAndIPMutablePod)
 		}
-		dp, ok := dpMap[appFullName]
-		if ok && isDeploymentKey(podFullName) {
-			if !p.hasResourceName(&dp.Spec.Template.Spec) {
-				// 6. deleted pods whose parent app's labels doesn't contain network=floatingip
-				if err := releaseIP(ipam, podFullName, deletedAndLabelMissMatchPod); err != nil {
-					glog.Warningf("[%s] %v", ipam.Name(), err)
-				}
-				continue
-			}
-			policy := parseReleasePolicy(&dp.Spec.Template.ObjectMeta)
-			if policy == constant.ReleasePolicyPodDelete {
-				// 2. deleted pods whose parent deployment exist but is not ip immutable
-				if err := releaseIP(ipam, podFullName, deletedAndIPMutablePod); err != nil {
-					glog.Warningf("[%s] %v", ipam.Name(), err)
-				}
-				continue
-			}
-			dpKey := deploymentIPPoolPrefix(dp)
-			fips, err := ipam.ByPrefix(dpKey)
-			if err != nil {
-				glog.Errorf("failed query prefix: %v", err)
-				continue
+		return nil
+	}
+	dp, ok := dpMap[appFullName]
+	if ok && isDeploymentKey(podFullName) {
+		if !p.hasResourceName(&dp.Spec.Template.Spec) {
+			// 6. deleted pods whose parent app's labels doesn't contain network=floatingip
+			return releaseIP(ipam, podFullName, deletedAndLabelMissMatchPod)
+		}
+		policy := parseReleasePolicy(&dp.Spec.Template.ObjectMeta)
+		if policy == constant.ReleasePolicyPodDelete {
+			// 2. deleted pods whose parent deployment exist but is not ip immutable
+			return releaseIP(ipam, podFullName, deletedAndIPMutablePod)
+		}
+		dpKey := deploymentIPPoolPrefix(dp)
+		fips, err := ipam.ByPrefix(dpKey)
+		if err != nil {
+			return fmt.Errorf("failed query prefix: %v", err)
+		}
+		replicas := int(*dp.Spec.Replicas)
+		if replicas < len(fips) && policy == constant.ReleasePolicyImmutable {
+			return releaseIP(ipam, podFullName, deletedAndScaledDownDpPod)
+		} else if dpKey != podFullName {
+			if err = ipam.UpdateKey(podFullName, dpKey); err != nil {
+				return fmt.Errorf("failed reserver deployment %s ip: %v", dpKey, err)
 			}
-			replicas := int(*dp.Spec.Replicas)
-			if replicas < len(fips) && policy == constant.ReleasePolicyImmutable {
-				if err = releaseIP(ipam, podFullName, deletedAndScaledDownDpPod); err != nil {
-					glog.Errorf("[%s] %v", ipam.Name(), err)
-				}
-			} else if dpKey != podFullName {
-				if err = ipam.UpdateKey(podFullName, dpKey); err != nil {
-					glog.Errorf("failed reserver deployment %s ip: %v", dpKey, err)
-				}
+		}
+		return nil
+	} else if isDeploymentKey(podFullName) {
+		appName, _, namespace := resolveDpAppPodName(podFullName)
+		fip, err := ipam.First(podFullName)
+		if err != nil {
+			return fmt.Errorf("failed get key %s: %v", podFullName, err)
+		} else if fip == nil {
+			return nil
+		}
+		if fip.FIP.Policy == uint16(constant.ReleasePolicyNever) {
+			var a Attr
+			if err := json.Unmarshal([]byte(attr), &a); err != nil {
+				return fmt.Errorf("failed to unmarshal attr %s for pod %s: %v", attr, podFullName, err)
 			}
-			continue
-		} else if isDeploymentKey(podFullName) {
-			appName, _, namespace := resolveDpAppPodName(podFullName)
-			fip, err := ipam.First(podFullName)
-			if err != nil {
-				glog.Errorf("failed get key %s: %v", podFullName, err)
-				continue
-			} else if fip == nil {
-				continue
+			prefixKey := a.Pool
+			if prefixKey == "" {
+				prefixKey = deploymentPrefix(appName, namespace)
 			}
-			if fip.FIP.Policy == uint16(constant.ReleasePolicyNever) {
-				var attr Attr
-				if err := json.Unmarshal([]byte(obj.attr), &attr); err != nil {
-					glog.Errorf("failed to unmarshal attr %s for pod %s: %v", obj.attr, podFullName, err)
-					continue
-				}
-				prefixKey := attr.Pool
-				if prefixKey == "" {
-					prefixKey = deploymentPrefix(appName, namespace)
-				}
-				if err = ipam.UpdateKey(podFullName, prefixKey); err != nil {
-					glog.Errorf("failed reserve fip: %v", err)
-				}
-			} else {
-				if err = releaseIP(ipam, podFullName, deletedAndIPMutablePod); err != nil {
-					glog.Errorf("failed release ip: %v", err)
-				}
+			if err = ipam.UpdateKey(podFullName, prefixKey); err != nil {
+				return fmt.Errorf("failed reserve fip: %v", err)
 			}
-			continue
+			return nil
 		}
+		return releaseIP(ipam, podFullName, deletedAndIPMutablePod)
 	}
 	return nil
 }
@@ -428,6 +427,13 @@ func (p *FloatingIPPlugin) syncIP(ipam floatingip.IPAM, key string, ip net.IP, p
 			return fmt.Errorf("conflict ip %s found for both %s and %s", ip.String(), key, storedKey)
 		}
 	} else {
+		name := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
+		if !p.checkConflict(name, ip.String(), pod) {
+			// a different, still-running pod already claims this ip in the
+			// pod cache; refuse to overwrite the existing binding and let
+			// the conflict event surface to the operator.
+			return fmt.Errorf("refusing to bind ip %s to %s: already claimed by another pod", ip.String(), key)
+		}
 		if err := ipam.AllocateSpecificIP(key, ip, parseReleasePolicy(&pod.ObjectMeta), getAttr(pod, pod.Spec.NodeName)); err != nil {
 			return err
 		}
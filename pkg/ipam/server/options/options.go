@@ -2,6 +2,7 @@ package options
 
 import (
 	"flag"
+	"time"
 
 	"github.com/spf13/pflag"
 )
@@ -16,6 +17,10 @@ type ServerRunOptions struct {
 	KubeConf       string
 	Swagger        bool
 	LeaderElection LeaderElectionConfiguration
+	// GCGracePeriod is how long a statefulset must have been observed at its
+	// current generation before the fast-scale gc guard will release a
+	// missing ordinal's floating ip.
+	GCGracePeriod time.Duration
 }
 
 var (
@@ -24,7 +29,8 @@ var (
 
 func init() {
 	flag.StringVar(&JsonConfigPath, "config", "/etc/galaxy/galaxy-ipam.json", "The json config file location of"+
-		" galaxy-ipam")
+		" galaxy-ipam. Only consulted once to bootstrap FloatingIPPool objects on first start; pools are"+
+		" managed as CRDs afterwards, see pkg/ipam/crd")
 }
 
 func NewServerRunOptions() *ServerRunOptions {
@@ -35,6 +41,7 @@ func NewServerRunOptions() *ServerRunOptions {
 		APIPort:        9041,
 		Swagger:        false,
 		LeaderElection: DefaultLeaderElectionConfiguration(),
+		GCGracePeriod:  30 * time.Second,
 	}
 	opt.LeaderElection.LeaderElect = true
 	return opt
@@ -49,5 +56,7 @@ func (s *ServerRunOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&s.Master, "master", s.Master, "The address and port of the Kubernetes API server")
 	fs.StringVar(&s.KubeConf, "kubeconfig", s.KubeConf, "The kube config file location of APISwitch, used to support TLS")
 	fs.BoolVar(&s.Swagger, "swagger", s.Swagger, "Enable swagger via API web interface host:api-port/apidocs.json/")
+	fs.DurationVar(&s.GCGracePeriod, "gc-grace-period", s.GCGracePeriod, "How long a statefulset must have been"+
+		" observed at its current generation before a missing ordinal's floating ip may be released")
 	BindFlags(&s.LeaderElection, fs)
 }
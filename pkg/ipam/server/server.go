@@ -0,0 +1,87 @@
+// Package server wires galaxy-ipam's pieces together: the FloatingIPPlugin
+// reconciler and the admin http server exposing its debug/health endpoints.
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"git.code.oa.com/gaiastack/galaxy/pkg/ipam/crd"
+	"git.code.oa.com/gaiastack/galaxy/pkg/ipam/schedulerplugin"
+	"git.code.oa.com/gaiastack/galaxy/pkg/ipam/server/options"
+	"github.com/golang/glog"
+	networkinginformers "k8s.io/client-go/informers/networking/v1"
+)
+
+// Server bundles the FloatingIPPlugin reconciler with the admin http server
+// that exposes its debug endpoints and, if configured via SetPoolController,
+// the FloatingIPPool CRD controller.
+type Server struct {
+	opt    *options.ServerRunOptions
+	plugin *schedulerplugin.FloatingIPPlugin
+
+	poolController *crd.Controller
+}
+
+// NewServer builds a Server around an already-constructed plugin, see
+// schedulerplugin.NewFloatingIPPlugin, applying the run options that affect
+// its behavior (e.g. --gc-grace-period) on top of its defaults.
+func NewServer(opt *options.ServerRunOptions, plugin *schedulerplugin.FloatingIPPlugin) *Server {
+	plugin.SetGCGracePeriod(opt.GCGracePeriod)
+	return &Server{opt: opt, plugin: plugin}
+}
+
+// SetPoolController enables the FloatingIPPool CRD controller (see
+// pkg/ipam/crd), replacing the --config JSON file as the source of truth for
+// pools after its one-time bootstrap. A Server that never calls this keeps
+// relying on the JSON config exactly as before. The plugin is wired in as
+// the controller's PoolSubnetObserver, so NetworkPolicy hints (if enabled
+// via SetNetworkPolicyHints) see real pool subnets.
+//
+// No code in this tree calls this yet: there is no cmd/ entrypoint here that
+// constructs a real crd.FloatingIPPoolsGetter (a generated clientset,
+// see crd.FloatingIPPoolsGetter's doc) or a crd.PoolConfigurator backed by
+// floatingip.IPAM (see crd.PoolConfigurator's doc for why that adapter isn't
+// written yet). Whatever does construct a Server needs to supply both.
+func (s *Server) SetPoolController(namespace string, client crd.FloatingIPPoolsGetter, configurator crd.PoolConfigurator) {
+	s.poolController = crd.NewController(namespace, client, configurator, s.plugin)
+}
+
+// SetNetworkPolicyHints enables NetworkPolicy-aware pool hints on the
+// plugin, see schedulerplugin.EnableNetworkPolicyHints. Call this before
+// SetPoolController if both are used, so hints are live before the first
+// pool subnets arrive.
+func (s *Server) SetNetworkPolicyHints(informer networkinginformers.NetworkPolicyInformer) {
+	s.plugin.EnableNetworkPolicyHints(informer)
+}
+
+// Run starts the admin http server and the plugin's reconciler, blocking
+// until stopCh is closed.
+func (s *Server) Run(stopCh <-chan struct{}) error {
+	mux := http.NewServeMux()
+	s.plugin.RegisterDebugRoutes(mux)
+	s.plugin.RegisterFilterRoute(mux)
+	addr := fmt.Sprintf("%s:%d", s.opt.Bind, s.opt.APIPort)
+	admin := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		glog.Infof("admin api server listening on %s", addr)
+		if err := admin.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			glog.Errorf("admin api server: %v", err)
+		}
+	}()
+	go func() {
+		<-stopCh
+		if err := admin.Close(); err != nil {
+			glog.Warningf("closing admin api server: %v", err)
+		}
+	}()
+	if s.poolController != nil {
+		go func() {
+			if err := s.poolController.Run(options.JsonConfigPath, stopCh); err != nil {
+				glog.Errorf("pool controller: %v", err)
+			}
+		}()
+	}
+	s.plugin.Run(stopCh)
+	return nil
+}